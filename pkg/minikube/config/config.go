@@ -0,0 +1,92 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/golang/glog"
+)
+
+// Loader loads and stores config from/to disk
+type Loader interface {
+	// LoadConfigFromFile loads profile's config, migrating it to
+	// CurrentSchemaVersion on disk first if it was written by an older
+	// version of minikube. migrated reports whether a migration ran.
+	LoadConfigFromFile(profile string, miniHome ...string) (cfg *Config, migrated bool, err error)
+}
+
+type simpleConfigLoader struct{}
+
+// DefaultLoader is the default config loader used by minikube
+var DefaultLoader Loader = &simpleConfigLoader{}
+
+// LoadConfigFromFile loads the configuration for the given profile from disk,
+// migrating it to CurrentSchemaVersion if it predates it.
+func (s *simpleConfigLoader) LoadConfigFromFile(profile string, miniHome ...string) (*Config, bool, error) {
+	path := profileFilePath(profile, miniHome...)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	raw := map[string]interface{}{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, false, err
+	}
+
+	fromVersion := rawSchemaVersion(raw)
+	migrated := fromVersion < CurrentSchemaVersion
+	if migrated {
+		// The pre-migration bytes are preserved by writeProfileConfig's own
+		// backup rotation below; a second, differently-named backup here
+		// would just be a duplicate of the same data.
+		raw, _, err = migrateToCurrent(raw, fromVersion)
+		if err != nil {
+			return nil, false, fmt.Errorf("migrating profile %s: %v", profile, err)
+		}
+	}
+
+	migratedData, err := json.Marshal(raw)
+	if err != nil {
+		return nil, false, err
+	}
+
+	cfg := &Config{}
+	if err := json.Unmarshal(migratedData, cfg); err != nil {
+		return nil, false, err
+	}
+
+	if migrated {
+		glog.Infof("profile %s migrated from schema v%d to v%d, rewriting on disk", profile, fromVersion, CurrentSchemaVersion)
+		// Rewrite through writeProfileConfig directly rather than CreateProfile:
+		// CreateProfile also triggers a hosts sync (a live API call plus an
+		// elevated /etc/hosts write), which must not be a side effect of a
+		// read path like ListProfiles.
+		rewritten, err := json.MarshalIndent(cfg, "", "    ")
+		if err != nil {
+			return nil, false, err
+		}
+		if err := writeProfileConfig(path, rewritten); err != nil {
+			return nil, false, fmt.Errorf("rewriting migrated profile %s: %v", profile, err)
+		}
+	}
+
+	return cfg, migrated, nil
+}
@@ -0,0 +1,242 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"k8s.io/minikube/pkg/util/elevate"
+)
+
+// hostsMu serializes writers to the hosts file within this process. It does
+// not help across processes: elevatedWriteFile falls back to a sudo/UAC copy
+// whenever the current process lacks permission to write the hosts file
+// directly, and that fallback bypasses lock.WriteFile's own locking
+// entirely. writeHostsBlock takes hostsLock around the same section to cover
+// that path too.
+var hostsMu sync.Mutex
+
+// hostsLockTimeout bounds how long writeHostsBlock waits for another process
+// to finish its own read-modify-write of the hosts file.
+const hostsLockTimeout = 30 * time.Second
+
+// hostsLockPollInterval is how often a blocked writeHostsBlock retries
+// acquiring hostsLock.
+const hostsLockPollInterval = 50 * time.Millisecond
+
+// hostsLockSuffix names the advisory lock file siting next to the hosts
+// file, so every process editing it contends for the same lock.
+const hostsLockSuffix = ".minikube-lock"
+
+// hostsLock acquires a cross-process advisory lock on path by atomically
+// creating a sibling lock file, retrying until it succeeds or timeout
+// elapses. It returns a function that releases the lock. Unlike
+// lock.WriteFile, this covers elevatedWriteFile's privilege-escalated
+// fallback too, since it is taken by the caller around the whole
+// read-modify-write rather than just the final write.
+func hostsLock(path string, timeout time.Duration) (release func(), err error) {
+	lockPath := path + hostsLockSuffix
+	deadline := time.Now().Add(timeout)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for hosts file lock %s", lockPath)
+		}
+		time.Sleep(hostsLockPollInterval)
+	}
+}
+
+// ingressListTimeout bounds how long a single Ingress List call may take, so
+// a cluster that is unreachable (e.g. a profile whose cluster isn't up yet)
+// can't hang SyncHosts, and the hostsMu-serialized callers behind it, forever.
+const ingressListTimeout = 5 * time.Second
+
+// clientsetForProfile builds a Kubernetes clientset for profile's cluster,
+// selecting it the same way the rest of minikube does: by kubeconfig context
+// name, which minikube always sets to the profile name. It is a variable so
+// tests can stub it out.
+var clientsetForProfile = func(profile string) (kubernetes.Interface, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: profile}
+	clientConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	clientConfig.Timeout = ingressListTimeout
+	return kubernetes.NewForConfig(clientConfig)
+}
+
+// SyncHosts connects to the profile's cluster, enumerates Ingress hostnames
+// across the configured namespace (or all namespaces), and writes them into a
+// managed block of the host's hosts file pointing at the profile's node IP.
+// Re-running SyncHosts replaces only the block belonging to this profile.
+func SyncHosts(profile string, miniHome ...string) error {
+	hostsMu.Lock()
+	defer hostsMu.Unlock()
+
+	p, _, err := loadProfile(profile, miniHome...)
+	if err != nil {
+		return fmt.Errorf("loading profile %s: %v", profile, err)
+	}
+
+	hosts, err := ingressHosts(profile, p.Config)
+	if err != nil {
+		return fmt.Errorf("enumerating ingress hosts for %s: %v", profile, err)
+	}
+
+	nodeIP := p.Config.MachineConfig.NodeIP
+	glog.Infof("syncing %d ingress host(s) for profile %s to %s", len(hosts), profile, nodeIP)
+	return writeHostsBlock(profile, nodeIP, hosts)
+}
+
+// PurgeHosts removes the managed block for profile from the hosts file, if present.
+func PurgeHosts(profile string, miniHome ...string) error {
+	hostsMu.Lock()
+	defer hostsMu.Unlock()
+
+	return writeHostsBlock(profile, "", nil)
+}
+
+// ingressHosts lists the hostnames of all Ingress resources visible to profile's cluster.
+func ingressHosts(profile string, cfg *Config) ([]string, error) {
+	cs, err := clientsetForProfile(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	namespace := cfg.KubernetesConfig.Namespace
+	if cfg.KubernetesConfig.AllNamespaces {
+		namespace = ""
+	}
+
+	list, err := cs.ExtensionsV1beta1().Ingresses(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var hosts []string
+	for _, ing := range list.Items {
+		for _, rule := range ing.Spec.Rules {
+			if rule.Host != "" {
+				hosts = append(hosts, rule.Host)
+			}
+		}
+	}
+	return hosts, nil
+}
+
+// markers returns the start/end lines that delimit profile's managed block.
+func markers(profile string) (start, end string) {
+	return fmt.Sprintf("# minikube-%s start", profile), fmt.Sprintf("# minikube-%s end", profile)
+}
+
+// writeHostsBlock replaces profile's managed block in the hosts file with one
+// entry per host in hosts pointing at nodeIP. Passing a nil hosts slice removes
+// the block entirely, which is how PurgeHosts is implemented. The whole
+// read-modify-write is held under hostsLock, so two profiles (or two
+// processes) editing the hosts file at once can't clobber each other's block.
+func writeHostsBlock(profile, nodeIP string, hosts []string) error {
+	path := hostsFilePath()
+
+	release, err := hostsLock(path, hostsLockTimeout)
+	if err != nil {
+		return fmt.Errorf("locking %s: %v", path, err)
+	}
+	defer release()
+
+	existing, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	start, end := markers(profile)
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(existing))
+	inBlock := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == start:
+			inBlock = true
+			continue
+		case line == end:
+			inBlock = false
+			continue
+		case inBlock:
+			continue
+		default:
+			out.WriteString(line)
+			out.WriteString("\n")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if len(hosts) > 0 {
+		out.WriteString(start)
+		out.WriteString("\n")
+		for _, h := range hosts {
+			fmt.Fprintf(&out, "%s\t%s\n", nodeIP, h)
+		}
+		out.WriteString(end)
+		out.WriteString("\n")
+	}
+
+	if bytes.Equal(out.Bytes(), existing) {
+		// Nothing changed: skip the elevated write so an unchanged sync
+		// doesn't prompt for sudo/UAC every time it runs.
+		return nil
+	}
+	return elevatedWriteFile(path, out.Bytes())
+}
+
+// elevatedWriteFile writes data to path, escalating privileges if necessary,
+// and is a variable so tests can avoid touching the real hosts file.
+var elevatedWriteFile = func(path string, data []byte) error {
+	return elevate.WriteFile(path, data, 0644)
+}
+
+// hostsFilePath returns the platform-specific location of the hosts file.
+// It is a variable so tests can point it at a scratch file instead of
+// touching the real one.
+var hostsFilePath = func() string {
+	if runtime.GOOS == "windows" {
+		return strings.Join([]string{os.Getenv("WINDIR"), "System32", "drivers", "etc", "hosts"}, string(os.PathSeparator))
+	}
+	return "/etc/hosts"
+}
@@ -0,0 +1,219 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// withStubHostsFile points hostsFilePath at a scratch file under a fresh
+// temp dir for the duration of the test, so writeHostsBlock's lock file and
+// reads never touch the real hosts file.
+func withStubHostsFile(t *testing.T) (path string) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "minikube-hosts-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path = filepath.Join(dir, "hosts")
+	origPath := hostsFilePath
+	hostsFilePath = func() string { return path }
+	t.Cleanup(func() { hostsFilePath = origPath })
+
+	return path
+}
+
+// stubElevatedWrites replaces elevatedWriteFile with one that writes straight
+// to disk (no privilege escalation) and counts how many times it was called,
+// so tests can assert writeHostsBlock skipped a no-op write.
+func stubElevatedWrites(t *testing.T) (calls *int) {
+	t.Helper()
+
+	calls = new(int)
+	orig := elevatedWriteFile
+	elevatedWriteFile = func(path string, data []byte) error {
+		*calls++
+		return ioutil.WriteFile(path, data, 0644)
+	}
+	t.Cleanup(func() { elevatedWriteFile = orig })
+	return calls
+}
+
+func TestWriteHostsBlockManagesOnlyOwnBlock(t *testing.T) {
+	path := withStubHostsFile(t)
+	calls := stubElevatedWrites(t)
+
+	if err := writeHostsBlock("a", "10.0.0.1", []string{"a.test"}); err != nil {
+		t.Fatalf("writeHostsBlock(a): %v", err)
+	}
+	if err := writeHostsBlock("b", "10.0.0.2", []string{"b.test"}); err != nil {
+		t.Fatalf("writeHostsBlock(b): %v", err)
+	}
+	if *calls != 2 {
+		t.Fatalf("elevatedWriteFile called %d times, want 2", *calls)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(data)
+	for _, want := range []string{"10.0.0.1\ta.test", "10.0.0.2\tb.test"} {
+		if !containsLine(content, want) {
+			t.Fatalf("hosts file %q missing line %q", content, want)
+		}
+	}
+
+	// Purging profile a's block must leave b's block untouched.
+	if err := writeHostsBlock("a", "", nil); err != nil {
+		t.Fatalf("writeHostsBlock(a, purge): %v", err)
+	}
+	data, err = ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content = string(data)
+	if containsLine(content, "10.0.0.1\ta.test") {
+		t.Fatalf("purged profile a's entry still present: %q", content)
+	}
+	if !containsLine(content, "10.0.0.2\tb.test") {
+		t.Fatalf("unrelated profile b's entry was removed: %q", content)
+	}
+}
+
+func TestWriteHostsBlockSkipsWriteWhenUnchanged(t *testing.T) {
+	withStubHostsFile(t)
+	calls := stubElevatedWrites(t)
+
+	if err := writeHostsBlock("a", "10.0.0.1", []string{"a.test"}); err != nil {
+		t.Fatalf("writeHostsBlock: %v", err)
+	}
+	if *calls != 1 {
+		t.Fatalf("elevatedWriteFile called %d times after first sync, want 1", *calls)
+	}
+
+	// Re-running with identical content must not trigger another elevated write.
+	if err := writeHostsBlock("a", "10.0.0.1", []string{"a.test"}); err != nil {
+		t.Fatalf("writeHostsBlock (no-op): %v", err)
+	}
+	if *calls != 1 {
+		t.Fatalf("elevatedWriteFile called %d times after unchanged sync, want still 1", *calls)
+	}
+
+	// A genuine change must still go through.
+	if err := writeHostsBlock("a", "10.0.0.1", []string{"a.test", "a2.test"}); err != nil {
+		t.Fatalf("writeHostsBlock (changed): %v", err)
+	}
+	if *calls != 2 {
+		t.Fatalf("elevatedWriteFile called %d times after a real change, want 2", *calls)
+	}
+}
+
+// TestSyncHostsUsesProfilesClientset exercises SyncHosts end-to-end with a
+// stubbed clientset and hosts file, verifying it selects the fake cluster's
+// Ingress hosts and writes them to the (stubbed) hosts file.
+func TestSyncHostsUsesProfilesClientset(t *testing.T) {
+	withStubHostsFile(t)
+	stubElevatedWrites(t)
+
+	origClientset := clientsetForProfile
+	clientsetForProfile = func(profile string) (kubernetes.Interface, error) {
+		return fake.NewSimpleClientset(&extensionsv1beta1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: "ing", Namespace: "default"},
+			Spec: extensionsv1beta1.IngressSpec{
+				Rules: []extensionsv1beta1.IngressRule{{Host: "synced.test"}},
+			},
+		}), nil
+	}
+	t.Cleanup(func() { clientsetForProfile = origClientset })
+
+	miniHome, err := ioutil.TempDir("", "minikube-hosts-sync-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(miniHome) })
+
+	const profile = "synced"
+	cfg := &Config{}
+	cfg.MachineConfig.NodeIP = "10.0.0.9"
+	cfg.KubernetesConfig.Namespace = "default"
+	if err := CreateProfile(profile, cfg, miniHome); err != nil {
+		t.Fatalf("CreateProfile: %v", err)
+	}
+
+	if err := SyncHosts(profile, miniHome); err != nil {
+		t.Fatalf("SyncHosts: %v", err)
+	}
+
+	data, err := ioutil.ReadFile(hostsFilePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !containsLine(string(data), "10.0.0.9\tsynced.test") {
+		t.Fatalf("hosts file %q missing synced ingress host", string(data))
+	}
+}
+
+func TestHostsLockExcludesConcurrentWriters(t *testing.T) {
+	path := withStubHostsFile(t)
+
+	release, err := hostsLock(path, hostsLockTimeout)
+	if err != nil {
+		t.Fatalf("hostsLock: %v", err)
+	}
+	defer release()
+
+	if _, err := hostsLock(path, 10*time.Millisecond); err == nil {
+		t.Fatal("second hostsLock acquired the same path while the first was still held")
+	}
+}
+
+func containsLine(content, line string) bool {
+	for _, l := range splitLines(content) {
+		if l == line {
+			return true
+		}
+	}
+	return false
+}
+
+func splitLines(s string) []string {
+	var lines []string
+	start := 0
+	for i, c := range s {
+		if c == '\n' {
+			lines = append(lines, s[start:i])
+			start = i + 1
+		}
+	}
+	if start < len(s) {
+		lines = append(lines, s[start:])
+	}
+	return lines
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import "fmt"
+
+// migrationFunc upgrades a raw, decoded profile config from the schema
+// version it was registered under to the next one.
+type migrationFunc func(raw map[string]interface{}) (map[string]interface{}, error)
+
+// migrations maps a fromVersion to the function that upgrades a profile from
+// that version to fromVersion+1. LoadConfigFromFile walks this chain
+// starting at a profile's detected version until it reaches
+// CurrentSchemaVersion.
+var migrations = map[int]migrationFunc{
+	0: migrateVMDriverToDriver,
+}
+
+// migrateVMDriverToDriver renames MachineConfig.VMDriver to
+// MachineConfig.Driver, the v0 -> v1 schema change.
+func migrateVMDriverToDriver(raw map[string]interface{}) (map[string]interface{}, error) {
+	mc, ok := raw["MachineConfig"].(map[string]interface{})
+	if !ok {
+		// No machine config to migrate (e.g. an empty profile); nothing to do.
+		return raw, nil
+	}
+	if driver, ok := mc["VMDriver"]; ok {
+		mc["Driver"] = driver
+		delete(mc, "VMDriver")
+	}
+	return raw, nil
+}
+
+// migrateToCurrent walks raw forward from fromVersion to CurrentSchemaVersion,
+// returning the migrated document and whether any migration ran.
+func migrateToCurrent(raw map[string]interface{}, fromVersion int) (map[string]interface{}, bool, error) {
+	migrated := false
+	version := fromVersion
+	for version < CurrentSchemaVersion {
+		migrate, ok := migrations[version]
+		if !ok {
+			return nil, false, fmt.Errorf("no migration registered from schema version %d", version)
+		}
+		var err error
+		raw, err = migrate(raw)
+		if err != nil {
+			return nil, false, fmt.Errorf("migrating from schema v%d: %v", version, err)
+		}
+		version++
+		raw["SchemaVersion"] = version
+		migrated = true
+	}
+	return raw, migrated, nil
+}
+
+// rawSchemaVersion reads the SchemaVersion field out of a decoded profile
+// document, treating a missing field as version 0.
+func rawSchemaVersion(raw map[string]interface{}) int {
+	v, ok := raw["SchemaVersion"]
+	if !ok {
+		return 0
+	}
+	// encoding/json decodes all numbers into map[string]interface{} as float64.
+	f, ok := v.(float64)
+	if !ok {
+		return 0
+	}
+	return int(f)
+}
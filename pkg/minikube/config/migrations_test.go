@@ -0,0 +1,154 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// loadTestdataProfile copies testdata fixture into a fresh $MINIKUBE_HOME
+// under profile and returns that home directory and the profile's config
+// file path.
+func loadTestdataProfile(t *testing.T, profile, fixture string) (miniHome, configPath string) {
+	t.Helper()
+
+	miniHome, err := ioutil.TempDir("", "minikube-migrations-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(miniHome) })
+
+	profileDir := filepath.Join(miniHome, "profiles", profile)
+	if err := os.MkdirAll(profileDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	configPath = filepath.Join(profileDir, "config.json")
+	if err := ioutil.WriteFile(configPath, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return miniHome, configPath
+}
+
+func TestLoadConfigFromFileMigratesSchemaV0(t *testing.T) {
+	const profile = "v0profile"
+	miniHome, configPath := loadTestdataProfile(t, profile, "schema-v0.json")
+
+	cfg, migrated, err := DefaultLoader.LoadConfigFromFile(profile, miniHome)
+	if err != nil {
+		t.Fatalf("LoadConfigFromFile: %v", err)
+	}
+	if !migrated {
+		t.Fatal("migrated = false, want true for a v0 fixture")
+	}
+	if cfg.SchemaVersion != CurrentSchemaVersion {
+		t.Fatalf("SchemaVersion = %d, want %d", cfg.SchemaVersion, CurrentSchemaVersion)
+	}
+	if cfg.MachineConfig.Driver != "virtualbox" {
+		t.Fatalf("Driver = %q, want %q", cfg.MachineConfig.Driver, "virtualbox")
+	}
+
+	onDisk, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var persisted Config
+	if err := json.Unmarshal(onDisk, &persisted); err != nil {
+		t.Fatal(err)
+	}
+	if persisted.SchemaVersion != CurrentSchemaVersion {
+		t.Fatalf("on-disk SchemaVersion = %d, want %d", persisted.SchemaVersion, CurrentSchemaVersion)
+	}
+	if persisted.MachineConfig.Driver != "virtualbox" {
+		t.Fatalf("on-disk Driver = %q, want %q", persisted.MachineConfig.Driver, "virtualbox")
+	}
+
+	backups, err := filepath.Glob(configPath + ".bak.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 {
+		t.Fatalf("got %d profile backups after migrating, want 1", len(backups))
+	}
+
+	// Loading an already-current profile must not re-migrate or re-back-up it.
+	cfg2, migratedAgain, err := DefaultLoader.LoadConfigFromFile(profile, miniHome)
+	if err != nil {
+		t.Fatalf("second LoadConfigFromFile: %v", err)
+	}
+	if migratedAgain {
+		t.Fatal("migrated = true on a profile already at CurrentSchemaVersion")
+	}
+	if cfg2.MachineConfig.Driver != "virtualbox" {
+		t.Fatalf("Driver after reload = %q, want %q", cfg2.MachineConfig.Driver, "virtualbox")
+	}
+	backups, err = filepath.Glob(configPath + ".bak.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 {
+		t.Fatalf("got %d profile backups after a no-op reload, want 1", len(backups))
+	}
+}
+
+func TestMigrateVMDriverToDriver(t *testing.T) {
+	raw := map[string]interface{}{
+		"MachineConfig": map[string]interface{}{
+			"VMDriver": "kvm2",
+		},
+	}
+	migrated, err := migrateVMDriverToDriver(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc := migrated["MachineConfig"].(map[string]interface{})
+	if _, ok := mc["VMDriver"]; ok {
+		t.Fatal("VMDriver should have been removed by the migration")
+	}
+	if mc["Driver"] != "kvm2" {
+		t.Fatalf("Driver = %v, want kvm2", mc["Driver"])
+	}
+}
+
+func TestCreateProfileWritesCurrentSchemaVersion(t *testing.T) {
+	miniHome, err := ioutil.TempDir("", "minikube-migrations-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(miniHome)
+
+	const profile = "fresh"
+	if err := CreateEmptyProfile(profile, miniHome); err != nil {
+		t.Fatalf("CreateEmptyProfile: %v", err)
+	}
+
+	_, migrated, err := DefaultLoader.LoadConfigFromFile(profile, miniHome)
+	if err != nil {
+		t.Fatalf("LoadConfigFromFile: %v", err)
+	}
+	if migrated {
+		t.Fatal("a profile just written by CreateProfile should not be detected as needing migration")
+	}
+}
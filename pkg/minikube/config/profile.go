@@ -18,18 +18,30 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/golang/glog"
 	"k8s.io/minikube/pkg/minikube/constants"
 	"k8s.io/minikube/pkg/util/lock"
 )
 
+// configBackupTimeFormat is lexicographically sortable, so backup files sort
+// chronologically by filename alone.
+const configBackupTimeFormat = "20060102T150405.000000000"
+
+// MaxConfigBackups is how many prior config.json revisions CreateProfile
+// keeps per profile before pruning the oldest.
+var MaxConfigBackups = 3
+
 // isValid checks if the profile has the essential info needed for a profile
 func (p *Profile) isValid() bool {
-	if p.Config.MachineConfig.VMDriver == "" {
+	if p.Config.MachineConfig.Driver == "" {
 		return false
 	}
 	if p.Config.KubernetesConfig.KubernetesVersion == "" {
@@ -58,6 +70,7 @@ func CreateEmptyProfile(name string, miniHome ...string) error {
 
 // CreateProfile creates an profile out of the cfg and stores in $MINIKUBE_HOME/profiles/<profilename>/config.json
 func CreateProfile(name string, cfg *Config, miniHome ...string) error {
+	cfg.SchemaVersion = CurrentSchemaVersion
 	data, err := json.MarshalIndent(cfg, "", "    ")
 	if err != nil {
 		return err
@@ -73,51 +86,210 @@ func CreateProfile(name string, cfg *Config, miniHome ...string) error {
 		if err := lock.WriteFile(path, data, 0600); err != nil {
 			return err
 		}
+		syncHostsBestEffort(name, miniHome...)
 		return nil
 	}
 
-	tf, err := ioutil.TempFile(filepath.Dir(path), "config.json.tmp")
+	if err := writeProfileConfig(path, data); err != nil {
+		return err
+	}
+	syncHostsBestEffort(name, miniHome...)
+	return nil
+}
+
+// writeProfileConfigFault is called with a step name between each syscall
+// writeProfileConfig performs; by default it does nothing, and it must not be
+// set to nil. It is a variable so tests can
+// inject a fault (e.g. a panic) at every step in turn and verify no ordering
+// ever leaves path unreadable.
+var writeProfileConfigFault = func(step string) {}
+
+// writeProfileConfig backs up the existing config at path, then atomically
+// replaces it with data via a temp-file-and-rename, fsyncing both the temp
+// file and its parent directory so a crash immediately before or after the
+// rename can never leave the profile unreadable. On Windows the replace is
+// not atomic: replaceConfigFile removes the old file before renaming, so a
+// crash between those two steps leaves no config.json in place.
+func writeProfileConfig(path string, data []byte) error {
+	if err := backupConfig(path); err != nil {
+		glog.Warningf("unable to back up %s: %v", path, err)
+	}
+	writeProfileConfigFault("after-backup")
+
+	dir := filepath.Dir(path)
+	tf, err := ioutil.TempFile(dir, "config.json.tmp")
 	if err != nil {
 		return err
 	}
 	defer os.Remove(tf.Name())
+	writeProfileConfigFault("after-create-temp")
+
+	if _, err := tf.Write(data); err != nil {
+		tf.Close()
+		return err
+	}
+	writeProfileConfigFault("after-write-temp")
+
+	if err := tf.Sync(); err != nil {
+		tf.Close()
+		return err
+	}
+	writeProfileConfigFault("after-sync-temp")
+
+	if err := tf.Close(); err != nil {
+		return err
+	}
+	writeProfileConfigFault("after-close-temp")
+
+	if err := replaceConfigFile(tf.Name(), path); err != nil {
+		return err
+	}
+	writeProfileConfigFault("after-rename")
+
+	if err := syncDir(dir); err != nil {
+		glog.Warningf("unable to fsync %s: %v", dir, err)
+	}
+	writeProfileConfigFault("after-sync-dir")
+	return nil
+}
+
+// backupConfig copies path's current contents aside as
+// config.json.bak.<timestamp> before it is overwritten, then prunes
+// anything beyond the newest MaxConfigBackups copies. A missing path (a
+// brand new profile) is not an error.
+func backupConfig(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	backupPath := configBackupPath(path, time.Now())
+	if err := ioutil.WriteFile(backupPath, data, 0600); err != nil {
+		return err
+	}
+	return pruneConfigBackups(path)
+}
 
-	if err = lock.WriteFile(tf.Name(), data, 0600); err != nil {
+// pruneConfigBackups removes all but the newest MaxConfigBackups backups of path.
+func pruneConfigBackups(path string) error {
+	backups, err := filepath.Glob(path + ".bak.*")
+	if err != nil {
 		return err
 	}
+	// configBackupTimeFormat sorts lexicographically in chronological order.
+	sort.Strings(backups)
+	if len(backups) <= MaxConfigBackups {
+		return nil
+	}
+	for _, b := range backups[:len(backups)-MaxConfigBackups] {
+		if err := os.Remove(b); err != nil {
+			glog.Warningf("unable to remove old profile backup %s: %v", b, err)
+		}
+	}
+	return nil
+}
 
-	if err = tf.Close(); err != nil {
+// configBackupPath returns the backup file name for path at instant at.
+func configBackupPath(path string, at time.Time) string {
+	return fmt.Sprintf("%s.bak.%s", path, at.UTC().Format(configBackupTimeFormat))
+}
+
+// syncDir fsyncs dir itself, so a rename of one of its entries is durable
+// across a crash or power loss, not just the entry's own contents.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
 		return err
 	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// RestoreProfile replaces profile's config with the newest backup taken at
+// or before at, as created by CreateProfile's backup rotation. It returns an
+// error if no such backup exists.
+func RestoreProfile(name string, at time.Time, miniHome ...string) error {
+	path := profileFilePath(name, miniHome...)
 
-	if err = os.Remove(path); err != nil {
+	backups, err := filepath.Glob(path + ".bak.*")
+	if err != nil {
 		return err
 	}
 
-	if err = os.Rename(tf.Name(), path); err != nil {
+	var bestPath string
+	var bestTime time.Time
+	for _, b := range backups {
+		ts, err := backupTimestamp(path, b)
+		if err != nil {
+			glog.Warningf("ignoring unparseable profile backup %s: %v", b, err)
+			continue
+		}
+		if ts.After(at) {
+			continue
+		}
+		if bestPath == "" || ts.After(bestTime) {
+			bestPath, bestTime = b, ts
+		}
+	}
+	if bestPath == "" {
+		return fmt.Errorf("no backup of profile %s found at or before %s", name, at)
+	}
+
+	data, err := ioutil.ReadFile(bestPath)
+	if err != nil {
 		return err
 	}
+	if err := writeProfileConfig(path, data); err != nil {
+		return err
+	}
+	syncHostsBestEffort(name, miniHome...)
 	return nil
 }
 
+// backupTimestamp parses the timestamp out of a backup file produced by
+// configBackupPath for the profile's config at path.
+func backupTimestamp(path, backupPath string) (time.Time, error) {
+	suffix := strings.TrimPrefix(backupPath, path+".bak.")
+	return time.Parse(configBackupTimeFormat, suffix)
+}
+
+// syncHostsBestEffort refreshes the profile's /etc/hosts block after a config
+// write. Hosts syncing is a convenience, not a correctness requirement, so a
+// failure (e.g. the cluster isn't up yet) is logged rather than propagated.
+func syncHostsBestEffort(name string, miniHome ...string) {
+	if err := SyncHosts(name, miniHome...); err != nil {
+		glog.Warningf("unable to sync hosts for profile %s: %v", name, err)
+	}
+}
+
 func DeleteProfile(profile string, miniHome ...string) error {
 	miniPath := constants.GetMinipath()
 	if len(miniHome) > 0 {
 		miniPath = miniHome[0]
 	}
+	if err := PurgeHosts(profile, miniHome...); err != nil {
+		glog.Warningf("unable to purge hosts for profile %s: %v", profile, err)
+	}
 	return os.RemoveAll(profileFolderPath(profile, miniPath))
 }
 
 // ListProfiles returns all valid and invalid (if any) minikube profiles
 // invalidPs are the profiles that have a directory or config file but not usable
 // invalidPs would be suggeted to be deleted
-func ListProfiles(miniHome ...string) (validPs []*Profile, inValidPs []*Profile, err error) {
+// migratedPs lists the names of profiles that were migrated to CurrentSchemaVersion while loading
+func ListProfiles(miniHome ...string) (validPs []*Profile, inValidPs []*Profile, migratedPs []string, err error) {
 	pDirs, err := profileDirs(miniHome...)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	for _, n := range pDirs {
-		p, err := loadProfile(n, miniHome...)
+		p, migrated, err := loadProfile(n, miniHome...)
+		if migrated {
+			migratedPs = append(migratedPs, n)
+		}
 		if err != nil {
 			inValidPs = append(inValidPs, p)
 			continue
@@ -128,17 +300,17 @@ func ListProfiles(miniHome ...string) (validPs []*Profile, inValidPs []*Profile,
 		}
 		validPs = append(validPs, p)
 	}
-	return validPs, inValidPs, nil
+	return validPs, inValidPs, migratedPs, nil
 }
 
 // loadProfile loads type Profile based on its name
-func loadProfile(name string, miniHome ...string) (*Profile, error) {
-	cfg, err := DefaultLoader.LoadConfigFromFile(name, miniHome...)
+func loadProfile(name string, miniHome ...string) (*Profile, bool, error) {
+	cfg, migrated, err := DefaultLoader.LoadConfigFromFile(name, miniHome...)
 	p := &Profile{
 		Name:   name,
 		Config: cfg,
 	}
-	return p, err
+	return p, migrated, err
 }
 
 // profileDirs gets all the folders in the user's profiles folder regardless of valid or invalid config
@@ -0,0 +1,97 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestWriteProfileConfigFaultTolerance panics writeProfileConfig at every
+// syscall boundary in turn and checks that path is always left holding
+// either the old or the new config, readable and parseable, never missing
+// or corrupt.
+func TestWriteProfileConfigFaultTolerance(t *testing.T) {
+	steps := []string{
+		"after-backup",
+		"after-create-temp",
+		"after-write-temp",
+		"after-sync-temp",
+		"after-close-temp",
+		"after-rename",
+		"after-sync-dir",
+	}
+
+	origFault := writeProfileConfigFault
+	defer func() { writeProfileConfigFault = origFault }()
+
+	const oldDriver = "virtualbox"
+	const newDriver = "kvm2"
+	oldData := []byte(`{"SchemaVersion":1,"MachineConfig":{"Driver":"` + oldDriver + `"}}`)
+	newData := []byte(`{"SchemaVersion":1,"MachineConfig":{"Driver":"` + newDriver + `"}}`)
+
+	for _, step := range steps {
+		step := step
+		t.Run(step, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "minikube-fault-test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			path := filepath.Join(dir, "config.json")
+			if err := ioutil.WriteFile(path, oldData, 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			writeProfileConfigFault = func(s string) {
+				if s == step {
+					panic("injected fault at " + s)
+				}
+			}
+
+			func() {
+				defer func() { recover() }()
+				writeProfileConfig(path, newData)
+			}()
+
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatalf("config.json unreadable after fault at %s: %v", step, err)
+			}
+			var cfg Config
+			if err := json.Unmarshal(data, &cfg); err != nil {
+				t.Fatalf("config.json unparseable after fault at %s: %v", step, err)
+			}
+			if cfg.MachineConfig.Driver != oldDriver && cfg.MachineConfig.Driver != newDriver {
+				t.Fatalf("config.json has neither old nor new content after fault at %s: %+v", step, cfg)
+			}
+
+			// No stray temp file should survive to be mistaken for the config.
+			leftovers, err := filepath.Glob(filepath.Join(dir, "config.json.tmp*"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(leftovers) > 0 {
+				t.Fatalf("temp file(s) left behind after fault at %s: %v", step, leftovers)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+// +build !windows
+
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import "os"
+
+// replaceConfigFile replaces path with tmpPath using os.Rename's atomic
+// same-filesystem replace semantics: at every point before and after the
+// syscall, path refers either to the old or the new contents, never neither.
+func replaceConfigFile(tmpPath, path string) error {
+	return os.Rename(tmpPath, path)
+}
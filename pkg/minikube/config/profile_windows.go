@@ -0,0 +1,32 @@
+// +build windows
+
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import "os"
+
+// replaceConfigFile replaces path with tmpPath. Windows' os.Rename refuses
+// to overwrite an existing file, so the old one is removed first; this
+// briefly reopens the crash window the POSIX atomic-replace rename avoids,
+// but the standard library exposes no atomic replace primitive here.
+func replaceConfigFile(tmpPath, path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
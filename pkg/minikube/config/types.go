@@ -0,0 +1,51 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+// CurrentSchemaVersion is the Config schema version written by this build of
+// minikube. Profiles on disk with an older (or missing) SchemaVersion are
+// brought up to date by the migrations registry in migrations.go.
+const CurrentSchemaVersion = 1
+
+// Profile represents a minikube profile
+type Profile struct {
+	Name   string
+	Config *Config
+}
+
+// Config contains all the parameters used to start a minikube cluster
+type Config struct {
+	// SchemaVersion identifies the shape of this struct as it was last
+	// written to disk. A missing value is treated as schema version 0.
+	SchemaVersion    int
+	MachineConfig    MachineConfig
+	KubernetesConfig KubernetesConfig
+}
+
+// MachineConfig contains the parameters used to start a cluster's host machine
+type MachineConfig struct {
+	Driver    string
+	NodeIP    string
+	DockerEnv []string
+}
+
+// KubernetesConfig contains the parameters used to start a cluster's Kubernetes control plane
+type KubernetesConfig struct {
+	KubernetesVersion string
+	Namespace         string
+	AllNamespaces     bool
+}
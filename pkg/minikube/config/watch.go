@@ -0,0 +1,313 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/golang/glog"
+	"k8s.io/minikube/pkg/minikube/constants"
+)
+
+// ProfileEventType describes the kind of change Watch observed for a profile.
+type ProfileEventType int
+
+const (
+	// Created indicates a profile's config.json was seen for the first time.
+	Created ProfileEventType = iota
+	// Updated indicates an existing profile's config.json changed.
+	Updated
+	// Deleted indicates a profile's config.json was removed.
+	Deleted
+)
+
+// ProfileEvent describes a single change to a profile's on-disk config.
+// Config is nil for Deleted events.
+type ProfileEvent struct {
+	Name   string
+	Type   ProfileEventType
+	Config *Config
+}
+
+// watchDebounce collapses the temp-file-write-then-rename sequence
+// CreateProfile performs into a single logical event per change. It is a
+// variable so tests can shorten it instead of waiting out the real delay.
+var watchDebounce = 250 * time.Millisecond
+
+// watchPollInterval is how often Watch checks for the profiles directory to
+// come into existence, for callers that start watching before minikube has
+// created its first profile.
+const watchPollInterval = time.Second
+
+// Watch streams a ProfileEvent for every create/update/delete of a profile's
+// config.json under $MINIKUBE_HOME/profiles until ctx is cancelled, at which
+// point the returned channel is closed. The profiles directory is allowed to
+// not exist yet; Watch waits for it to appear rather than failing.
+func Watch(ctx context.Context) (<-chan ProfileEvent, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan ProfileEvent)
+	go runWatch(ctx, watcher, events)
+	return events, nil
+}
+
+// profileWatch holds the mutable state runWatch needs while it streams events.
+type profileWatch struct {
+	ctx     context.Context
+	events  chan<- ProfileEvent
+	watcher *fsnotify.Watcher
+	mu      sync.Mutex
+	known   map[string]bool
+	watched map[string]bool
+	timers  map[string]*time.Timer
+	// wg tracks debounce timers that have fired (or are about to) and have
+	// not yet finished their emit/send, so events isn't closed out from
+	// under a goroutine still trying to write to it.
+	wg sync.WaitGroup
+}
+
+func runWatch(ctx context.Context, watcher *fsnotify.Watcher, events chan<- ProfileEvent) {
+	defer close(events)
+	defer watcher.Close()
+
+	w := &profileWatch{
+		ctx:     ctx,
+		events:  events,
+		watcher: watcher,
+		known:   map[string]bool{},
+		watched: map[string]bool{},
+		timers:  map[string]*time.Timer{},
+	}
+	// Runs before watcher.Close()/close(events) above (defers unwind LIFO),
+	// so any debounce callback still sending on events finishes first.
+	defer w.wg.Wait()
+
+	if !w.waitForProfilesDir() {
+		return
+	}
+	for _, n := range existingProfileDirs() {
+		w.addProfileDir(n)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.stopTimers()
+			return
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			glog.Warningf("profile watcher error: %v", err)
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			w.handleEvent(ev)
+		}
+	}
+}
+
+// waitForProfilesDir blocks, polling at watchPollInterval, until the
+// profiles directory exists and a watch has been placed on it. It returns
+// false if ctx is cancelled first.
+func (w *profileWatch) waitForProfilesDir() bool {
+	root := profilesDir()
+	for {
+		if _, err := os.Stat(root); err == nil {
+			if err := w.watcher.Add(root); err != nil {
+				glog.Warningf("unable to watch profiles dir %s: %v", root, err)
+			} else {
+				return true
+			}
+		}
+		select {
+		case <-w.ctx.Done():
+			return false
+		case <-time.After(watchPollInterval):
+		}
+	}
+}
+
+func (w *profileWatch) handleEvent(ev fsnotify.Event) {
+	dir := filepath.Dir(ev.Name)
+	base := filepath.Base(ev.Name)
+
+	if dir == profilesDir() {
+		// A profile's own directory appeared; start watching config.json inside it.
+		if ev.Op&fsnotify.Create != 0 {
+			w.addProfileDir(base)
+		}
+		return
+	}
+
+	if base == "config.json" {
+		name := filepath.Base(dir)
+		w.mu.Lock()
+		isWatched := w.watched[name]
+		w.mu.Unlock()
+		if isWatched {
+			w.schedule(name)
+		}
+	}
+}
+
+func (w *profileWatch) addProfileDir(name string) {
+	w.mu.Lock()
+	if w.watched[name] {
+		w.mu.Unlock()
+		return
+	}
+	w.watched[name] = true
+	w.mu.Unlock()
+
+	if err := w.watcher.Add(profileFolderPath(name)); err != nil {
+		glog.Warningf("unable to watch profile %s: %v", name, err)
+		return
+	}
+	w.schedule(name)
+}
+
+// forgetProfile clears every bit of state addProfileDir/schedule accumulated
+// for name, once its config.json is confirmed gone. Without this, watched
+// stays true forever: a later "minikube delete -p name && minikube start -p
+// name" recreates the profile directory, but addProfileDir's watched[name]
+// check short-circuits before watcher.Add ever sees the new directory inode,
+// so the recreated profile stops producing events for the life of Watch.
+func (w *profileWatch) forgetProfile(name string) {
+	// Best-effort: the directory is already gone, so this mostly just drops
+	// fsnotify's bookkeeping for the dead inode a little earlier.
+	if err := w.watcher.Remove(profileFolderPath(name)); err != nil {
+		glog.V(2).Infof("unable to unwatch profile %s: %v", name, err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.known, name)
+	delete(w.watched, name)
+	if t, ok := w.timers[name]; ok {
+		if t.Stop() {
+			w.wg.Done()
+		}
+		delete(w.timers, name)
+	}
+}
+
+// schedule (re)starts the debounce timer for name, so a burst of rapid
+// rename+write events collapses into a single emitted ProfileEvent.
+func (w *profileWatch) schedule(name string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if t, ok := w.timers[name]; ok && t.Stop() {
+		// Stopped before it fired, so its wg slot is released here; if Stop
+		// returned false the old callback already fired (or is running) and
+		// will release its own slot via wg.Done.
+		w.wg.Done()
+	}
+	w.wg.Add(1)
+	w.timers[name] = time.AfterFunc(watchDebounce, func() {
+		defer w.wg.Done()
+		w.emit(name)
+	})
+}
+
+// stopTimers cancels every pending debounce timer, releasing the wg slot
+// reserved for each one that hadn't fired yet.
+func (w *profileWatch) stopTimers() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for name, t := range w.timers {
+		if t.Stop() {
+			w.wg.Done()
+		}
+		delete(w.timers, name)
+	}
+}
+
+// emit loads name's current config and sends the appropriate ProfileEvent,
+// tracking whether this is the first time name has been seen so it can
+// distinguish Created from Updated.
+func (w *profileWatch) emit(name string) {
+	w.mu.Lock()
+	delete(w.timers, name)
+	wasKnown := w.known[name]
+	w.mu.Unlock()
+
+	cfg, _, err := DefaultLoader.LoadConfigFromFile(name)
+	if err != nil {
+		// Only tear down tracking once the profile's directory is actually
+		// gone; config.json alone can transiently fail to load (e.g. the
+		// directory was just created and the file hasn't been written yet),
+		// and that must not stop the directory from being watched.
+		if _, statErr := os.Stat(profileFolderPath(name)); os.IsNotExist(statErr) {
+			w.forgetProfile(name)
+		}
+		if wasKnown {
+			w.send(ProfileEvent{Name: name, Type: Deleted})
+		}
+		return
+	}
+
+	evType := Updated
+	if !wasKnown {
+		evType = Created
+		w.mu.Lock()
+		w.known[name] = true
+		w.mu.Unlock()
+	}
+	w.send(ProfileEvent{Name: name, Type: evType, Config: cfg})
+}
+
+func (w *profileWatch) send(ev ProfileEvent) {
+	select {
+	case w.events <- ev:
+	case <-w.ctx.Done():
+	}
+}
+
+func profilesDir() string {
+	return filepath.Join(constants.GetMinipath(), "profiles")
+}
+
+// existingProfileDirs returns the profile directories already on disk,
+// logging rather than failing if the profiles dir can't be read.
+func existingProfileDirs() []string {
+	dirs, err := profileDirs()
+	if err != nil {
+		glog.Warningf("unable to list profiles: %v", err)
+		return nil
+	}
+	return dirs
+}
+
+// LastModified returns when profile's config.json was last written, so a
+// Watch consumer can reconcile any events it missed while disconnected.
+func LastModified(profile string, miniHome ...string) (time.Time, error) {
+	info, err := os.Stat(profileFilePath(profile, miniHome...))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// withTestMinipath points constants.GetMinipath (via $MINIKUBE_HOME) at a
+// fresh temp dir with an empty profiles directory already in place, for the
+// duration of the test.
+func withTestMinipath(t *testing.T) (miniHome string) {
+	t.Helper()
+
+	miniHome, err := ioutil.TempDir("", "minikube-watch-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(miniHome) })
+
+	if err := os.MkdirAll(filepath.Join(miniHome, "profiles"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	origHome, hadHome := os.LookupEnv("MINIKUBE_HOME")
+	if err := os.Setenv("MINIKUBE_HOME", miniHome); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if hadHome {
+			os.Setenv("MINIKUBE_HOME", origHome)
+		} else {
+			os.Unsetenv("MINIKUBE_HOME")
+		}
+	})
+
+	return miniHome
+}
+
+// withShortWatchDebounce shortens watchDebounce for the duration of the
+// test, so tests don't have to wait out the real 250ms delay.
+func withShortWatchDebounce(t *testing.T, d time.Duration) {
+	t.Helper()
+	orig := watchDebounce
+	watchDebounce = d
+	t.Cleanup(func() { watchDebounce = orig })
+}
+
+// recvEvent waits up to timeout for an event on events, failing the test if
+// none arrives in time.
+func recvEvent(t *testing.T, events <-chan ProfileEvent, timeout time.Duration) ProfileEvent {
+	t.Helper()
+	select {
+	case ev, ok := <-events:
+		if !ok {
+			t.Fatal("events channel closed while waiting for an event")
+		}
+		return ev
+	case <-time.After(timeout):
+		t.Fatal("timed out waiting for an event")
+	}
+	return ProfileEvent{}
+}
+
+func TestWatchClosesOnContextCancelMidDebounce(t *testing.T) {
+	withTestMinipath(t)
+	withShortWatchDebounce(t, 200*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := Watch(ctx)
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+
+	if err := CreateEmptyProfile("mid-debounce"); err != nil {
+		t.Fatalf("CreateEmptyProfile: %v", err)
+	}
+
+	// The debounce timer for the create above is now pending (watchDebounce
+	// is 200ms); cancel well before it can fire.
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	select {
+	case ev, ok := <-events:
+		if ok {
+			t.Fatalf("got event %+v after cancel, want the channel to close with no pending event delivered", ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("events channel never closed after ctx was cancelled mid-debounce")
+	}
+}
+
+func TestWatchDeleteThenRecreateProfile(t *testing.T) {
+	miniHome := withTestMinipath(t)
+	withShortWatchDebounce(t, 50*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := Watch(ctx)
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+
+	const profile = "recreate-me"
+	profileDir := filepath.Join(miniHome, "profiles", profile)
+
+	if err := CreateEmptyProfile(profile); err != nil {
+		t.Fatalf("CreateEmptyProfile: %v", err)
+	}
+	if ev := recvEvent(t, events, 2*time.Second); ev.Type != Created || ev.Name != profile {
+		t.Fatalf("got %+v, want a Created event for %s", ev, profile)
+	}
+
+	if err := os.RemoveAll(profileDir); err != nil {
+		t.Fatal(err)
+	}
+	if ev := recvEvent(t, events, 2*time.Second); ev.Type != Deleted || ev.Name != profile {
+		t.Fatalf("got %+v, want a Deleted event for %s", ev, profile)
+	}
+
+	// Before the watched-map leak was fixed, recreating the profile here
+	// never produced another event: addProfileDir's watched[name] check
+	// short-circuited on the stale true left over from before the delete.
+	if err := CreateEmptyProfile(profile); err != nil {
+		t.Fatalf("CreateEmptyProfile (recreate): %v", err)
+	}
+	if ev := recvEvent(t, events, 2*time.Second); ev.Type != Created || ev.Name != profile {
+		t.Fatalf("got %+v, want a Created event for the recreated profile %s", ev, profile)
+	}
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package elevate provides helpers for performing filesystem writes that
+// require administrative privileges (e.g. editing /etc/hosts), prompting
+// the user for escalation (sudo on Unix, UAC on Windows) rather than
+// requiring the whole minikube binary to run as an elevated user.
+package elevate
+
+import (
+	"io/ioutil"
+	"os"
+
+	"k8s.io/minikube/pkg/util/lock"
+)
+
+// WriteFile writes data to path, serializing concurrent writers the same way
+// CreateProfile does, and escalating privileges if the current process does
+// not already have permission to write to path.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	if err := lock.WriteFile(path, data, perm); err == nil {
+		return nil
+	} else if !os.IsPermission(err) {
+		return err
+	}
+
+	tf, err := ioutil.TempFile("", "minikube-elevate")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tf.Name())
+
+	if err := ioutil.WriteFile(tf.Name(), data, perm); err != nil {
+		return err
+	}
+
+	return elevatedCopy(tf.Name(), path)
+}
@@ -0,0 +1,32 @@
+// +build windows
+
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package elevate
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// elevatedCopy copies src to dst via a UAC-elevated PowerShell process,
+// which prompts the user for consent.
+func elevatedCopy(src, dst string) error {
+	script := fmt.Sprintf("Start-Process -FilePath cmd.exe -ArgumentList '/c copy /y \"%s\" \"%s\"' -Verb RunAs -Wait", src, dst)
+	cmd := exec.Command("powershell.exe", "-NoProfile", "-Command", script)
+	return cmd.Run()
+}